@@ -1,30 +1,55 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
-	"github.com/remeh/sizedwaitgroup"
+	"golang.org/x/sync/errgroup"
 
+	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/nccgroup/go-pillage-registries/pkg/pillage"
+	"github.com/nccgroup/go-pillage-registries/pkg/pillage/scan"
 	"github.com/spf13/cobra"
 )
 
 var (
 	// Used for flags.
-	repos       []string
-	tags        []string
-	skiptls     bool
-	insecure    bool
-	storeImages bool
-	registry    string
-	cachePath   string
-	resultsPath string
-	workerCount int
+	repos            []string
+	tags             []string
+	skiptls          bool
+	insecure         bool
+	storeImages      bool
+	registry         string
+	cachePath        string
+	resultsPath      string
+	username         string
+	password         string
+	passwordStdin    bool
+	bearerToken      string
+	dockerConfigPath string
+	authRegistries   []string
+	anonFirst        bool
+	rulesPath        string
+	platforms        []string
+	allPlatforms     bool
+	registryWorkers  int
+	repoWorkers      int
+	tagWorkers       int
+	pullWorkers      int
+	failFast         bool
+	outputFormat     string
+	enumSignatures   bool
+	cosignKeyPath    string
 )
 
 func init() {
@@ -36,7 +61,30 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&insecure, "insecure", "i", false, "Fetch Data over plaintext")
 	rootCmd.PersistentFlags().BoolVarP(&storeImages, "store-images", "s", false, "Downloads filesystem for discovered images and stores an archive in the output directory (Disabled by default, requires --results to be set)")
 	rootCmd.PersistentFlags().StringVarP(&cachePath, "cache", "c", "", "Path to cache image layers (optional, only used if images are pulled)")
-	rootCmd.PersistentFlags().IntVarP(&workerCount, "workers", "w", 8, "Number of workers when pulling images. If set too high, this may cause errors. (optional, only used if images are pulled)")
+	rootCmd.PersistentFlags().IntVarP(&pullWorkers, "pull-workers", "w", 8, "Number of workers storing/pulling images concurrently. If set too high, this may cause errors. (optional, only used if images are pulled)")
+
+	rootCmd.PersistentFlags().StringVar(&username, "username", "", "Username for registry authentication, applied to any registry not covered by --auth-registry")
+	rootCmd.PersistentFlags().StringVar(&password, "password", "", "Password for registry authentication")
+	rootCmd.PersistentFlags().BoolVar(&passwordStdin, "password-stdin", false, "Read the registry password from stdin instead of --password")
+	rootCmd.PersistentFlags().StringVar(&bearerToken, "bearer-token", "", "Bearer token for registry authentication, takes precedence over --username/--password")
+	rootCmd.PersistentFlags().StringVar(&dockerConfigPath, "docker-config", "", "Path to a docker config.json to read credentials from (defaults to ~/.docker/config.json)")
+	rootCmd.PersistentFlags().StringSliceVar(&authRegistries, "auth-registry", []string{}, "Per-registry credentials as host=user:pass (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&anonFirst, "anon-first", false, "Attempt anonymous catalog/tag listing before authenticating")
+
+	rootCmd.PersistentFlags().StringVar(&rulesPath, "rules", "", "Path to a YAML file of additional secret-scanning rules to load alongside the built-in rule set")
+
+	rootCmd.PersistentFlags().StringSliceVar(&platforms, "platform", []string{}, "Platforms to enumerate from a multi-arch image, as os/arch[/variant] (e.g. linux/amd64). Defaults to pilreg's own platform")
+	rootCmd.PersistentFlags().BoolVar(&allPlatforms, "all-platforms", false, "Enumerate every platform in a multi-arch image instead of filtering by --platform")
+
+	rootCmd.PersistentFlags().IntVar(&registryWorkers, "registry-workers", 0, "Maximum registries to enumerate concurrently (0 is unbounded)")
+	rootCmd.PersistentFlags().IntVar(&repoWorkers, "repo-workers", 0, "Maximum repositories to enumerate concurrently per registry (0 is unbounded)")
+	rootCmd.PersistentFlags().IntVar(&tagWorkers, "tag-workers", 0, "Maximum tags to enumerate concurrently per repository (0 is unbounded)")
+	rootCmd.PersistentFlags().BoolVar(&failFast, "fail-fast", false, "Cancel the whole scan on the first fatal enumeration error instead of recording it and continuing")
+
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output-format", pillage.OutputFormatDir, "How to lay out pulled image filesystems: dir, oci-layout, or oci-archive (requires --store-images and --results)")
+
+	rootCmd.PersistentFlags().BoolVar(&enumSignatures, "signatures", false, "Probe each image for cosign signatures, attestations, and SBOMs via the sha256-<digest>.sig/.att/.sbom tag convention (extra round trips per image)")
+	rootCmd.PersistentFlags().StringVar(&cosignKeyPath, "cosign-key", "", "Path to a PEM-encoded cosign ECDSA public key to verify discovered signatures against (requires --signatures)")
 }
 
 var rootCmd = &cobra.Command{
@@ -48,46 +96,148 @@ var rootCmd = &cobra.Command{
 
 func run(_ *cobra.Command, registries []string) {
 
+	//ctx is cancelled on SIGINT/SIGTERM, which aborts in-flight catalog/tag
+	//listings and crane.Pull/crane.Save calls instead of leaking them.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	//Transport options
 	if skiptls {
 		http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
 
-	craneoptions := pillage.MakeCraneOptions(insecure)
+	if passwordStdin {
+		scanner := bufio.NewScanner(os.Stdin)
+		if !scanner.Scan() {
+			log.Fatalf("Error reading password from stdin: %v", scanner.Err())
+		}
+		password = strings.TrimSuffix(scanner.Text(), "\r")
+	}
+
+	authOptions := &pillage.AuthOptions{
+		Username:         username,
+		Password:         password,
+		BearerToken:      bearerToken,
+		DockerConfigPath: dockerConfigPath,
+	}
+	if len(authRegistries) > 0 {
+		authOptions.PerRegistry = make(map[string]pillage.RegistryCredential, len(authRegistries))
+		for _, entry := range authRegistries {
+			host, cred, err := pillage.ParseAuthRegistry(entry)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			authOptions.PerRegistry[host] = cred
+		}
+	}
+
+	craneoptions := append(pillage.MakeCraneOptions(insecure, authOptions), crane.WithContext(ctx))
 
 	//Validate and initalize storage options
 	if storeImages && resultsPath == "" {
 		log.Fatalf("Cannot pull images without destination path. Unset --pull-images or set --results")
 	}
+	if outputFormat != pillage.OutputFormatDir && (!storeImages || resultsPath == "") {
+		log.Fatalf("--output-format %s requires --store-images and --results", outputFormat)
+	}
+	scanner, err := scan.NewScanner(rulesPath)
+	if err != nil {
+		log.Fatalf("Error loading secret-scanning rules: %v", err)
+	}
+
+	var ociLayoutDir string
+	var ociLayout *pillage.OCILayout
+	switch outputFormat {
+	case pillage.OutputFormatDir:
+	case pillage.OutputFormatOCILayout, pillage.OutputFormatOCIArchive:
+		ociLayoutDir = filepath.Join(resultsPath, "oci-layout")
+		ociLayout, err = pillage.OpenOCILayout(ociLayoutDir)
+		if err != nil {
+			log.Fatalf("Error creating OCI image layout: %v", err)
+		}
+	default:
+		log.Fatalf("Invalid --output-format %q: want dir, oci-layout, or oci-archive", outputFormat)
+	}
+
+	platformFilter := &pillage.PlatformFilter{All: allPlatforms}
+	for _, p := range platforms {
+		parsed, err := pillage.ParsePlatform(p)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		platformFilter.Platforms = append(platformFilter.Platforms, parsed)
+	}
+
+	var cosignOpts *pillage.CosignOptions
+	if enumSignatures {
+		cosignOpts = &pillage.CosignOptions{}
+		if cosignKeyPath != "" {
+			key, err := pillage.LoadCosignPublicKey(cosignKeyPath)
+			if err != nil {
+				log.Fatalf("Error loading --cosign-key: %v", err)
+			}
+			cosignOpts.PublicKey = key
+		}
+	}
+
 	storageOptions := &pillage.StorageOptions{
 		StoreImages:  storeImages,
 		CachePath:    cachePath,
 		ResultsPath:  resultsPath,
 		CraneOptions: craneoptions,
+		Scanner:      scanner,
+		OutputFormat: outputFormat,
+		OCILayout:    ociLayout,
+	}
+
+	enumOptions := &pillage.EnumOptions{
+		AnonFirst:  anonFirst,
+		Platforms:  platformFilter,
+		FailFast:   failFast,
+		Signatures: enumSignatures,
+		Cosign:     cosignOpts,
+		Concurrency: pillage.ConcurrencyOptions{
+			Registries:   registryWorkers,
+			Repositories: repoWorkers,
+			Tags:         tagWorkers,
+		},
 	}
 
 	//Enumerate images from registries
-	images := pillage.EnumRegistries(registries, repos, tags, craneoptions...)
+	images := pillage.EnumRegistries(ctx, registries, repos, tags, enumOptions, craneoptions...)
 
 	//Collect images and store results
 	var results []*pillage.ImageData
-	wg := sizedwaitgroup.New(workerCount)
+	storeGroup, _ := errgroup.WithContext(ctx)
+	storeGroup.SetLimit(pullWorkers)
 
 	for image := range images {
 
 		if resultsPath == "" {
 			results = append(results, image)
 		} else {
-			wg.Add()
-			go func(image *pillage.ImageData) {
+			image := image
+			storeGroup.Go(func() error {
 				image.Store(storageOptions)
-				wg.Done()
-			}(image)
+				return nil
+			})
 		}
 
 	}
 
-	wg.Wait()
+	storeGroup.Wait()
+
+	if outputFormat == pillage.OutputFormatOCIArchive {
+		archivePath := filepath.Join(resultsPath, "oci-archive.tar")
+		if err := pillage.WriteArchive(ociLayoutDir, archivePath); err != nil {
+			log.Fatalf("Error writing OCI archive: %v", err)
+		}
+		//the archive now carries everything oci-layout held; drop the
+		//uncompressed staging directory so only oci-archive.tar remains
+		if err := os.RemoveAll(ociLayoutDir); err != nil {
+			log.Printf("Error removing OCI layout staging directory %s: %v", ociLayoutDir, err)
+		}
+	}
 
 	if resultsPath == "" {
 		out, err := json.Marshal(results)
@@ -0,0 +1,232 @@
+package pillage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+//RegistryCredential is a single username/password pair scoped to one registry host.
+type RegistryCredential struct {
+	Username string
+	Password string
+}
+
+//AuthOptions collects every credential source pilreg can draw from when resolving
+//an authn.Authenticator for a given registry. It implements authn.Keychain, so it
+//can be passed directly to crane.WithAuthFromKeychain.
+type AuthOptions struct {
+	//Username/Password are used for any registry not covered by PerRegistry.
+	Username string
+	Password string
+	//BearerToken, if set, takes precedence over Username/Password.
+	BearerToken string
+	//PerRegistry maps a registry host (as returned by authn.Resource.RegistryStr)
+	//to credentials that apply only to that host. Populated from --auth-registry.
+	PerRegistry map[string]RegistryCredential
+	//DockerConfigPath, if set, is parsed instead of the default ~/.docker/config.json.
+	DockerConfigPath string
+
+	//dockerConfigOnce/dockerConfig/dockerConfigErr cache the result of loading
+	//DockerConfigPath, since Resolve is called by go-containerregistry on
+	//essentially every registry request: re-reading and re-parsing the docker
+	//config file per call would be wasteful, and re-exec-ing a credential
+	//helper (which may itself hit a cloud STS/metadata endpoint) per call
+	//risks hitting that provider's rate limits during a bulk scan.
+	dockerConfigOnce sync.Once
+	dockerConfig     *dockerConfig
+	dockerConfigErr  error
+
+	//credentialCacheMu/credentialCache cache the per-registry result of
+	//dockerConfig.credential, so a registry's credential helper is only
+	//invoked once for the lifetime of these AuthOptions.
+	credentialCacheMu sync.Mutex
+	credentialCache   map[string]credentialCacheEntry
+}
+
+//credentialCacheEntry caches one dockerConfig.credential lookup, including
+//misses, since ok must be remembered alongside the zero-value RegistryCredential.
+type credentialCacheEntry struct {
+	cred RegistryCredential
+	ok   bool
+}
+
+//ParseAuthRegistry parses a single --auth-registry value of the form
+//"host=user:pass" into its host and credential.
+func ParseAuthRegistry(value string) (host string, cred RegistryCredential, err error) {
+	host, rest, ok := strings.Cut(value, "=")
+	if !ok {
+		return "", RegistryCredential{}, fmt.Errorf("invalid --auth-registry value %q, want host=user:pass", value)
+	}
+	user, pass, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", RegistryCredential{}, fmt.Errorf("invalid --auth-registry value %q, want host=user:pass", value)
+	}
+	return host, RegistryCredential{Username: user, Password: pass}, nil
+}
+
+//Resolve implements authn.Keychain, picking the best available credential for
+//target's registry: an explicit per-registry credential, then a global bearer
+//token or username/password, then the docker config (credHelpers/credsStore/auths),
+//and finally anonymous access.
+func (opts *AuthOptions) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if opts == nil {
+		return authn.Anonymous, nil
+	}
+
+	registry := target.RegistryStr()
+
+	if cred, ok := opts.PerRegistry[registry]; ok {
+		return &authn.Basic{Username: cred.Username, Password: cred.Password}, nil
+	}
+
+	if opts.BearerToken != "" {
+		return &authn.Bearer{Token: opts.BearerToken}, nil
+	}
+
+	if opts.Username != "" {
+		return &authn.Basic{Username: opts.Username, Password: opts.Password}, nil
+	}
+
+	cfg, err := opts.loadDockerConfigOnce()
+	if err != nil {
+		return nil, fmt.Errorf("loading docker config for %s: %w", registry, err)
+	}
+	if cred, ok := opts.cachedCredential(cfg, registry); ok {
+		return &authn.Basic{Username: cred.Username, Password: cred.Password}, nil
+	}
+
+	return authn.Anonymous, nil
+}
+
+//loadDockerConfigOnce loads and parses DockerConfigPath the first time it's
+//called and returns the cached result on every subsequent call.
+func (opts *AuthOptions) loadDockerConfigOnce() (*dockerConfig, error) {
+	opts.dockerConfigOnce.Do(func() {
+		opts.dockerConfig, opts.dockerConfigErr = loadDockerConfig(opts.DockerConfigPath)
+	})
+	return opts.dockerConfig, opts.dockerConfigErr
+}
+
+//cachedCredential wraps cfg.credential with a per-registry cache, so a
+//registry's credential helper subprocess is only invoked once rather than
+//once per Resolve call.
+func (opts *AuthOptions) cachedCredential(cfg *dockerConfig, registry string) (RegistryCredential, bool) {
+	opts.credentialCacheMu.Lock()
+	defer opts.credentialCacheMu.Unlock()
+
+	if entry, ok := opts.credentialCache[registry]; ok {
+		return entry.cred, entry.ok
+	}
+
+	cred, ok := cfg.credential(registry)
+	if opts.credentialCache == nil {
+		opts.credentialCache = make(map[string]credentialCacheEntry)
+	}
+	opts.credentialCache[registry] = credentialCacheEntry{cred: cred, ok: ok}
+	return cred, ok
+}
+
+//dockerConfig is the subset of ~/.docker/config.json that pilreg understands:
+//per-registry auths, plus the credHelpers/credsStore used by cloud provider
+//credential helpers (ecr-login, gcloud, acr-login, and friends).
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+	CredHelpers map[string]string `json:"credHelpers"`
+	CredsStore  string            `json:"credsStore"`
+}
+
+//loadDockerConfig reads a docker config.json from path, or from
+//~/.docker/config.json if path is blank. A missing file is not an error; it
+//yields an empty config so callers fall through to anonymous access.
+func loadDockerConfig(path string) (*dockerConfig, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return &dockerConfig{}, nil
+		}
+		path = filepath.Join(home, ".docker", "config.json")
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &dockerConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading docker config %s: %w", path, err)
+	}
+
+	var cfg dockerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing docker config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+//credential resolves the credential docker itself would use for registry,
+//checking credHelpers, then credsStore, then the auths map.
+func (cfg *dockerConfig) credential(registry string) (RegistryCredential, bool) {
+	if helper, ok := cfg.CredHelpers[registry]; ok {
+		if cred, err := credentialHelperGet(helper, registry); err == nil {
+			return cred, true
+		}
+	}
+
+	if cfg.CredsStore != "" {
+		if cred, err := credentialHelperGet(cfg.CredsStore, registry); err == nil {
+			return cred, true
+		}
+	}
+
+	if entry, ok := cfg.Auths[registry]; ok {
+		if entry.Username != "" || entry.Password != "" {
+			return RegistryCredential{Username: entry.Username, Password: entry.Password}, true
+		}
+		if entry.Auth != "" {
+			if decoded, err := base64.StdEncoding.DecodeString(entry.Auth); err == nil {
+				if user, pass, ok := strings.Cut(string(decoded), ":"); ok {
+					return RegistryCredential{Username: user, Password: pass}, true
+				}
+			}
+		}
+	}
+
+	return RegistryCredential{}, false
+}
+
+//credentialHelperGet invokes `docker-credential-<helper> get`, following the
+//protocol documented at
+//https://docs.docker.com/engine/reference/commandline/login/#credential-helpers:
+//the registry host is written to the helper's stdin and a JSON object
+//carrying Username/Secret is read back from its stdout.
+func credentialHelperGet(helper, registry string) (RegistryCredential, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(registry)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return RegistryCredential{}, fmt.Errorf("running docker-credential-%s: %w", helper, err)
+	}
+
+	var resp struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return RegistryCredential{}, fmt.Errorf("parsing docker-credential-%s output: %w", helper, err)
+	}
+	return RegistryCredential{Username: resp.Username, Password: resp.Secret}, nil
+}
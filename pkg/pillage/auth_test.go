@@ -0,0 +1,165 @@
+package pillage
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestParseAuthRegistry(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    string
+		wantHost string
+		wantCred RegistryCredential
+		wantErr  bool
+	}{
+		{
+			name:     "host user and pass",
+			value:    "registry.example.com=alice:hunter2",
+			wantHost: "registry.example.com",
+			wantCred: RegistryCredential{Username: "alice", Password: "hunter2"},
+		},
+		{
+			name:     "password may itself contain a colon",
+			value:    "registry.example.com=alice:hunter2:extra",
+			wantHost: "registry.example.com",
+			wantCred: RegistryCredential{Username: "alice", Password: "hunter2:extra"},
+		},
+		{
+			name:    "missing =",
+			value:   "registry.example.com alice:hunter2",
+			wantErr: true,
+		},
+		{
+			name:    "missing :",
+			value:   "registry.example.com=alice",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			host, cred, err := ParseAuthRegistry(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseAuthRegistry(%q) = nil error, want one", tc.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAuthRegistry(%q) returned error: %v", tc.value, err)
+			}
+			if host != tc.wantHost || cred != tc.wantCred {
+				t.Errorf("ParseAuthRegistry(%q) = (%q, %+v), want (%q, %+v)", tc.value, host, cred, tc.wantHost, tc.wantCred)
+			}
+		})
+	}
+}
+
+func TestAuthOptionsResolveCachesDockerConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	writeDockerConfig := func(user, pass string) {
+		auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+		content := `{"auths":{"registry.example.com":{"auth":"` + auth + `"}}}`
+		if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+			t.Fatalf("writing docker config: %v", err)
+		}
+	}
+	writeDockerConfig("alice", "hunter2")
+
+	opts := &AuthOptions{DockerConfigPath: configPath}
+	target, err := name.NewRegistry("registry.example.com")
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	auth, err := opts.Resolve(target)
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		t.Fatalf("Authorization returned error: %v", err)
+	}
+	if cfg.Username != "alice" || cfg.Password != "hunter2" {
+		t.Fatalf("Resolve = %+v, want alice:hunter2", cfg)
+	}
+
+	//the config file now points at a different credential; a cached Resolve
+	//must not re-read it and should keep returning the first credential.
+	writeDockerConfig("bob", "s3cret")
+
+	auth, err = opts.Resolve(target)
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	cfg, err = auth.Authorization()
+	if err != nil {
+		t.Fatalf("Authorization returned error: %v", err)
+	}
+	if cfg.Username != "alice" || cfg.Password != "hunter2" {
+		t.Fatalf("second Resolve = %+v, want the cached alice:hunter2 credential", cfg)
+	}
+}
+
+func TestDockerConfigCredential(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("bob:s3cret"))
+
+	cfg := &dockerConfig{
+		Auths: map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}{
+			"explicit.example.com": {Username: "alice", Password: "hunter2", Auth: encoded},
+			"encoded.example.com":  {Auth: encoded},
+			"empty.example.com":    {},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		registry string
+		wantCred RegistryCredential
+		wantOK   bool
+	}{
+		{
+			name:     "explicit username and password take precedence",
+			registry: "explicit.example.com",
+			wantCred: RegistryCredential{Username: "alice", Password: "hunter2"},
+			wantOK:   true,
+		},
+		{
+			name:     "base64 auth is decoded",
+			registry: "encoded.example.com",
+			wantCred: RegistryCredential{Username: "bob", Password: "s3cret"},
+			wantOK:   true,
+		},
+		{
+			name:     "entry with neither field set is not a credential",
+			registry: "empty.example.com",
+			wantOK:   false,
+		},
+		{
+			name:     "unknown registry",
+			registry: "unknown.example.com",
+			wantOK:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cred, ok := cfg.credential(tc.registry)
+			if ok != tc.wantOK {
+				t.Fatalf("credential(%q) ok = %v, want %v", tc.registry, ok, tc.wantOK)
+			}
+			if ok && cred != tc.wantCred {
+				t.Errorf("credential(%q) = %+v, want %+v", tc.registry, cred, tc.wantCred)
+			}
+		})
+	}
+}
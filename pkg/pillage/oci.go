@@ -0,0 +1,98 @@
+package pillage
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+)
+
+//Output formats accepted by --output-format: "dir" lays out one directory per
+//image as pilreg always has; "oci-layout" additionally materializes every
+//pulled image into a single OCI image layout directory; "oci-archive" does the
+//same and then tars that directory into a single file.
+const (
+	OutputFormatDir        = "dir"
+	OutputFormatOCILayout  = "oci-layout"
+	OutputFormatOCIArchive = "oci-archive"
+)
+
+//OCILayout wraps a layout.Path so that many goroutines can safely append
+//images discovered during a scan to the same OCI image layout directory.
+type OCILayout struct {
+	mu   sync.Mutex
+	path layout.Path
+}
+
+//OpenOCILayout creates (or replaces) an OCI image layout directory at dir.
+func OpenOCILayout(dir string) (*OCILayout, error) {
+	path, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		return nil, fmt.Errorf("creating OCI image layout at %s: %w", dir, err)
+	}
+	return &OCILayout{path: path}, nil
+}
+
+//AppendImage adds img to the layout, annotating it with ref so the image it
+//came from is still identifiable once it's just another blob under
+//blobs/sha256.
+func (o *OCILayout) AppendImage(img v1.Image, ref string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.path.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"org.opencontainers.image.ref.name": ref,
+	}))
+}
+
+//WriteArchive tars the OCI image layout directory at dir into a single file at
+//archivePath, producing the "oci-archive" format consumed by skopeo/buildah.
+func WriteArchive(dir, archivePath string) error {
+	archiveFile, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("creating archive %s: %w", archivePath, err)
+	}
+	defer archiveFile.Close()
+
+	tw := tar.NewWriter(archiveFile)
+	defer tw.Close()
+
+	return filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = relPath
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
@@ -0,0 +1,44 @@
+package pillage
+
+//ConcurrencyOptions bounds how many goroutines each enumeration stage may run
+//at once. A zero value leaves that stage unbounded.
+type ConcurrencyOptions struct {
+	Registries   int
+	Repositories int
+	Tags         int
+}
+
+//EnumOptions bundles the behavior shared by EnumRegistries/EnumRegistry/
+//EnumRepository/EnumImage: authentication/platform selection, concurrency
+//limits, and how enumeration errors are handled. A nil *EnumOptions is
+//equivalent to the zero value everywhere it's accepted.
+type EnumOptions struct {
+	//AnonFirst makes catalog/tag listing try an anonymous request first and
+	//only authenticate if the registry challenges with 401.
+	AnonFirst bool
+	//Platforms selects which platforms of a multi-arch image to recurse into.
+	Platforms *PlatformFilter
+	//Concurrency bounds the number of goroutines running at each stage.
+	Concurrency ConcurrencyOptions
+	//FailFast cancels the whole enumeration on the first fatal error instead
+	//of recording it on the affected ImageData and continuing.
+	FailFast bool
+	//Signatures enables probing each image for cosign signatures,
+	//attestations, and SBOMs via the sha256-<digest>.sig/.att/.sbom tag
+	//convention. Left off by default, since it costs extra round trips per
+	//image that most scans of open registries don't want to pay.
+	Signatures bool
+	//Cosign configures optional verification of signatures found when
+	//Signatures is set. May be nil even when Signatures is set, in which case
+	//signatures are recorded but left unverified.
+	Cosign *CosignOptions
+}
+
+//orDefault returns opts, or an empty *EnumOptions if opts is nil, so callers
+//can dereference fields without a nil check at every use.
+func (opts *EnumOptions) orDefault() *EnumOptions {
+	if opts == nil {
+		return &EnumOptions{}
+	}
+	return opts
+}
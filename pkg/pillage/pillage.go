@@ -1,17 +1,27 @@
 package pillage
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
-	"sync"
 
+	"golang.org/x/sync/errgroup"
+
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/cache"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/nccgroup/go-pillage-registries/pkg/pillage/scan"
 )
 
 //ImageData represents an image enumerated from a registry or alternatively an error that occured while enumerating a registry.
@@ -22,7 +32,28 @@ type ImageData struct {
 	Tag        string
 	Manifest   string
 	Config     string
-	Error      error
+	Findings   []scan.Finding `json:",omitempty"`
+	//Platform is set when this ImageData was selected from a multi-arch
+	//manifest list/image index rather than a single-platform manifest.
+	Platform *Platform `json:",omitempty"`
+	//IndexManifest carries the raw manifest list/image index this ImageData
+	//was selected from, so Store can persist it once per tag as index.json.
+	IndexManifest string `json:",omitempty"`
+	//Signatures, Attestations, and SBOMs are populated when EnumOptions.Signatures
+	//is set, by probing the cosign sha256-<digest>.sig/.att/.sbom tag convention.
+	Signatures   []Signature   `json:",omitempty"`
+	Attestations []Attestation `json:",omitempty"`
+	SBOMs        []SBOM        `json:",omitempty"`
+	//IndexSignatures, IndexAttestations, and IndexSBOMs are populated when
+	//EnumOptions.Signatures is set and this ImageData was selected from a
+	//manifest list/image index: `cosign sign` signs the index digest itself
+	//by default, not each platform child, so the index digest is probed
+	//separately from Signatures/Attestations/SBOMs above. Store persists
+	//these once per tag alongside IndexManifest.
+	IndexSignatures   []Signature   `json:",omitempty"`
+	IndexAttestations []Attestation `json:",omitempty"`
+	IndexSBOMs        []SBOM        `json:",omitempty"`
+	Error        error
 }
 
 //StorageOptions is passed to ImageData.Store to set the location and options for pulling the image data.
@@ -31,17 +62,76 @@ type StorageOptions struct {
 	ResultsPath  string
 	StoreImages  bool
 	CraneOptions []crane.Option
+	//Scanner, if set, is run over each image's config, manifest, and (when
+	//StoreImages is set) layer filesystems to look for leaked secrets.
+	Scanner *scan.Scanner
+	//OutputFormat selects how a pulled image's filesystem is persisted: as a
+	//standalone filesystem.tar (OutputFormatDir, the default), or appended to
+	//OCILayout (OutputFormatOCILayout/OutputFormatOCIArchive).
+	OutputFormat string
+	//OCILayout is the shared OCI image layout images are appended to when
+	//OutputFormat is OutputFormatOCILayout or OutputFormatOCIArchive.
+	OCILayout *OCILayout
 }
 
-//MakeCraneOption initalizes an array of crane options for use when interacting with a registry
-func MakeCraneOptions(insecure bool) (options []crane.Option) {
+//MakeCraneOptions initalizes an array of crane options for use when interacting with a registry.
+//auth may be nil, in which case all registries are accessed anonymously.
+func MakeCraneOptions(insecure bool, auth *AuthOptions) (options []crane.Option) {
 	if insecure {
 		options = append(options, crane.Insecure)
 	}
 
+	if auth != nil {
+		options = append(options, crane.WithAuthFromKeychain(auth))
+	} else {
+		options = append(options, crane.WithAuth(authn.Anonymous))
+	}
+
 	return options
 }
 
+//isUnauthorized reports whether err is a registry response carrying HTTP 401,
+//i.e. the registry challenged an anonymous request and expects credentials.
+func isUnauthorized(err error) bool {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return terr.StatusCode == http.StatusUnauthorized
+	}
+	return false
+}
+
+//catalog lists the repositories on reg, trying an anonymous request first when
+//anonFirst is set and only falling back to options (which may carry auth) if
+//the registry challenges with 401.
+func catalog(reg string, anonFirst bool, options ...crane.Option) ([]string, error) {
+	if anonFirst {
+		repos, err := crane.Catalog(reg, append(append([]crane.Option{}, options...), crane.WithAuth(authn.Anonymous))...)
+		if err == nil {
+			return repos, nil
+		}
+		if !isUnauthorized(err) {
+			return nil, err
+		}
+	}
+	return crane.Catalog(reg, options...)
+}
+
+//listTags lists the tags for ref, trying an anonymous request first when
+//anonFirst is set and only falling back to options (which may carry auth) if
+//the registry challenges with 401.
+func listTags(ref string, anonFirst bool, options ...crane.Option) ([]string, error) {
+	if anonFirst {
+		tags, err := crane.ListTags(ref, append(append([]crane.Option{}, options...), crane.WithAuth(authn.Anonymous))...)
+		if err == nil {
+			return tags, nil
+		}
+		if !isUnauthorized(err) {
+			return nil, err
+		}
+	}
+	return crane.ListTags(ref, options...)
+}
+
 func securejoin(paths ...string) (out string) {
 	for _, path := range paths {
 		out = filepath.Join(out, filepath.Clean("/"+path))
@@ -54,7 +144,11 @@ func (image *ImageData) Store(options *StorageOptions) error {
 	log.Printf("Storing results for image: %s", image.Reference)
 
 	//make image output dir
-	imagePath := filepath.Join(options.ResultsPath, securejoin(image.Registry, image.Repository, image.Tag))
+	tagPath := filepath.Join(options.ResultsPath, securejoin(image.Registry, image.Repository, image.Tag))
+	imagePath := tagPath
+	if image.Platform != nil {
+		imagePath = filepath.Join(tagPath, securejoin(image.Platform.dirName()))
+	}
 	err := os.MkdirAll(imagePath, os.ModePerm)
 	if err != nil {
 		log.Printf("Error making storage path %s: %v", imagePath, err)
@@ -63,6 +157,30 @@ func (image *ImageData) Store(options *StorageOptions) error {
 
 	log.Printf("Storing results for image: %s", image.Reference)
 
+	//store the manifest list/image index this image was selected from
+	if image.IndexManifest != "" {
+		indexPath := path.Join(tagPath, "index.json")
+		if err := ioutil.WriteFile(indexPath, []byte(image.IndexManifest), os.ModePerm); err != nil {
+			log.Printf("Error making index file %s: %v", indexPath, err)
+		}
+	}
+
+	//store cosign signatures/attestations/SBOMs found on the index digest itself
+	if len(image.IndexSignatures) > 0 || len(image.IndexAttestations) > 0 || len(image.IndexSBOMs) > 0 {
+		indexSignaturesPath := path.Join(tagPath, "index-signatures.json")
+		summary := struct {
+			Signatures   []Signature   `json:"signatures,omitempty"`
+			Attestations []Attestation `json:"attestations,omitempty"`
+			SBOMs        []SBOM        `json:"sboms,omitempty"`
+		}{image.IndexSignatures, image.IndexAttestations, image.IndexSBOMs}
+		out, err := json.Marshal(summary)
+		if err != nil {
+			log.Printf("Error formatting index signatures for %s: %v", indexSignaturesPath, err)
+		} else if err := ioutil.WriteFile(indexSignaturesPath, out, os.ModePerm); err != nil {
+			log.Printf("Error making index signatures file %s: %v", indexSignaturesPath, err)
+		}
+	}
+
 	//store image config
 	if image.Config != "" {
 		configPath := path.Join(imagePath, "config.json")
@@ -83,6 +201,17 @@ func (image *ImageData) Store(options *StorageOptions) error {
 		}
 	}
 
+	//scan the config and manifest for leaked secrets (env vars live in the
+	//config's container_config, so scanning the raw config covers them too)
+	if options.Scanner != nil {
+		if image.Config != "" {
+			image.Findings = append(image.Findings, options.Scanner.ScanBytes("", "config.json", []byte(image.Config))...)
+		}
+		if image.Manifest != "" {
+			image.Findings = append(image.Findings, options.Scanner.ScanBytes("", "manifest.json", []byte(image.Manifest))...)
+		}
+	}
+
 	//pull and save the image if asked
 	if image.Error == nil && options.StoreImages {
 
@@ -94,17 +223,66 @@ func (image *ImageData) Store(options *StorageOptions) error {
 			fs = cache.Image(fs, cache.NewFilesystemCache(options.CachePath))
 		}
 
-		fsPath := path.Join(imagePath, "filesystem.tar")
-		if err := crane.Save(fs, image.Reference, fsPath); err != nil {
-			log.Printf("Error saving tarball %s: %v", fsPath, err)
-			if image.Error == nil {
-				image.Error = err
-			} else {
-				image.Error = errors.New(image.Error.Error() + err.Error())
+		if options.Scanner != nil {
+			findings, err := options.Scanner.ScanImage(fs, 0)
+			if err != nil {
+				log.Printf("Error scanning layers for %s: %v", image.Reference, err)
+			}
+			image.Findings = append(image.Findings, findings...)
+		}
+
+		switch options.OutputFormat {
+		case OutputFormatOCILayout, OutputFormatOCIArchive:
+			if options.OCILayout != nil {
+				if err := options.OCILayout.AppendImage(fs, image.Reference); err != nil {
+					log.Printf("Error appending %s to OCI image layout: %v", image.Reference, err)
+					if image.Error == nil {
+						image.Error = err
+					} else {
+						image.Error = errors.New(image.Error.Error() + err.Error())
+					}
+				}
+			}
+		default:
+			fsPath := path.Join(imagePath, "filesystem.tar")
+			if err := crane.Save(fs, image.Reference, fsPath); err != nil {
+				log.Printf("Error saving tarball %s: %v", fsPath, err)
+				if image.Error == nil {
+					image.Error = err
+				} else {
+					image.Error = errors.New(image.Error.Error() + err.Error())
+				}
 			}
 		}
 	}
 
+	//store findings
+	if len(image.Findings) > 0 {
+		findingsPath := path.Join(imagePath, "findings.json")
+		out, err := json.Marshal(image.Findings)
+		if err != nil {
+			log.Printf("Error formatting findings for %s: %v", findingsPath, err)
+		} else if err := ioutil.WriteFile(findingsPath, out, os.ModePerm); err != nil {
+			log.Printf("Error making findings file %s: %v", findingsPath, err)
+		}
+	}
+
+	//store cosign signatures/attestations/SBOMs
+	if len(image.Signatures) > 0 || len(image.Attestations) > 0 || len(image.SBOMs) > 0 {
+		signaturesPath := path.Join(imagePath, "signatures.json")
+		summary := struct {
+			Signatures   []Signature   `json:"signatures,omitempty"`
+			Attestations []Attestation `json:"attestations,omitempty"`
+			SBOMs        []SBOM        `json:"sboms,omitempty"`
+		}{image.Signatures, image.Attestations, image.SBOMs}
+		out, err := json.Marshal(summary)
+		if err != nil {
+			log.Printf("Error formatting signatures for %s: %v", signaturesPath, err)
+		} else if err := ioutil.WriteFile(signaturesPath, out, os.ModePerm); err != nil {
+			log.Printf("Error making signatures file %s: %v", signaturesPath, err)
+		}
+	}
+
 	//store errors
 	if image.Error != nil {
 		errorPath := path.Join(imagePath, "errors.log")
@@ -117,43 +295,173 @@ func (image *ImageData) Store(options *StorageOptions) error {
 }
 
 //EnumImage will read a specific image from a remote registry and returns the result asynchronously.
-func EnumImage(reg string, repo string, tag string, options ...crane.Option) <-chan *ImageData {
+//If the image turns out to be a manifest list/image index, it recurses into the
+//child manifests selected by opts.Platforms and returns one ImageData per platform.
+func EnumImage(ctx context.Context, reg string, repo string, tag string, opts *EnumOptions, options ...crane.Option) <-chan *ImageData {
+	opts = opts.orDefault()
 	out := make(chan *ImageData)
 
-	ref := fmt.Sprintf("%s/%s:%s", reg, repo, tag)
-
-	go func(ref string) {
+	go func() {
 		defer close(out)
 
+		if ctx.Err() != nil {
+			return
+		}
+
+		ref, err := buildReference(reg, repo, tag)
+		if err != nil {
+			log.Printf("Error building reference for %s/%s %s: %s", reg, repo, tag, err)
+			out <- &ImageData{Registry: reg, Repository: repo, Tag: tag, Error: err}
+			return
+		}
+
+		manifest, err := crane.Manifest(ref, options...)
+		if err != nil {
+			log.Printf("Error fetching manifest for image %s: %s", ref, err)
+			out <- &ImageData{Reference: ref, Registry: reg, Repository: repo, Tag: tag, Error: err}
+			return
+		}
+
+		if isIndexMediaType(manifestMediaType(manifest)) {
+			enumImageIndex(ctx, out, reg, repo, tag, ref, manifest, opts, options...)
+			return
+		}
+
 		result := &ImageData{
 			Reference:  ref,
 			Registry:   reg,
 			Repository: repo,
 			Tag:        tag,
+			Manifest:   string(manifest),
 		}
 
-		manifest, err := crane.Manifest(ref, options...)
+		config, err := crane.Config(ref, options...)
 		if err != nil {
-			log.Printf("Error fetching manifest for image %s: %s", ref, err)
+			log.Printf("Error fetching config for image %s: %s (the config may be in the manifest itself)", ref, err)
+		}
+		result.Config = string(config)
+
+		populateSignatures(result, ref, reg, repo, opts, options...)
+
+		out <- result
+	}()
+
+	return out
+}
+
+//populateSignatures probes the cosign sha256-<digest>.sig/.att/.sbom tag
+//convention for ref and records whatever it finds on result, when
+//opts.Signatures is set. A missing tag or digest lookup failure just leaves
+//result's signature fields empty; most images aren't signed.
+func populateSignatures(result *ImageData, ref, reg, repo string, opts *EnumOptions, options ...crane.Option) {
+	if !opts.Signatures {
+		return
+	}
+
+	digest, err := crane.Digest(ref, options...)
+	if err != nil {
+		log.Printf("Error getting digest for %s: %s", ref, err)
+		return
+	}
+
+	hash, err := v1.NewHash(digest)
+	if err != nil {
+		log.Printf("Error parsing digest %s for %s: %s", digest, ref, err)
+		return
+	}
+
+	result.Signatures, result.Attestations, result.SBOMs = EnumSignatures(reg, repo, hash, opts.Cosign, options...)
+}
+
+//populateIndexSignatures probes the cosign tag convention for the digest of
+//a manifest list/image index itself (rather than one of its per-platform
+//children) and records whatever it finds on result. `cosign sign` signs the
+//index digest by default when pushed against a multi-arch tag, so this must
+//run in addition to, not instead of, populateSignatures on each child.
+func populateIndexSignatures(result *ImageData, indexManifest []byte, reg, repo string, opts *EnumOptions, options ...crane.Option) {
+	if !opts.Signatures {
+		return
+	}
+
+	hash, _, err := v1.SHA256(bytes.NewReader(indexManifest))
+	if err != nil {
+		log.Printf("Error hashing image index for %s/%s: %s", reg, repo, err)
+		return
+	}
+
+	result.IndexSignatures, result.IndexAttestations, result.IndexSBOMs = EnumSignatures(reg, repo, hash, opts.Cosign, options...)
+}
+
+//enumImageIndex parses a manifest list/image index, recurses into each child
+//descriptor selected by opts.Platforms, and emits one ImageData per platform.
+func enumImageIndex(ctx context.Context, out chan<- *ImageData, reg, repo, tag, ref string, indexManifest []byte, opts *EnumOptions, options ...crane.Option) {
+	index, err := parseImageIndex(indexManifest)
+	if err != nil {
+		log.Printf("Error parsing image index for %s: %s", ref, err)
+		out <- &ImageData{Reference: ref, Registry: reg, Repository: repo, Tag: tag, Manifest: string(indexManifest), Error: err}
+		return
+	}
+
+	indexSignatures := &ImageData{}
+	populateIndexSignatures(indexSignatures, indexManifest, reg, repo, opts, options...)
+
+	for _, desc := range index.Manifests {
+		if ctx.Err() != nil {
+			return
+		}
+		if !opts.Platforms.matches(desc.Platform) {
+			continue
+		}
+
+		platform := platformOf(desc.Platform)
+		childRef := fmt.Sprintf("%s/%s@%s", reg, repo, desc.Digest)
+		childOptions := options
+		if platform != nil {
+			childOptions = append(append([]crane.Option{}, options...), crane.WithPlatform(&v1.Platform{
+				OS:           platform.OS,
+				Architecture: platform.Architecture,
+				Variant:      platform.Variant,
+				OSVersion:    platform.OSVersion,
+			}))
+		}
+
+		result := &ImageData{
+			Reference:         childRef,
+			Registry:          reg,
+			Repository:        repo,
+			Tag:               tag,
+			Platform:          platform,
+			IndexManifest:     string(indexManifest),
+			IndexSignatures:   indexSignatures.IndexSignatures,
+			IndexAttestations: indexSignatures.IndexAttestations,
+			IndexSBOMs:        indexSignatures.IndexSBOMs,
+		}
+
+		manifest, err := crane.Manifest(childRef, childOptions...)
+		if err != nil {
+			log.Printf("Error fetching manifest for image %s: %s", childRef, err)
 			result.Error = err
+			out <- result
+			continue
 		}
 		result.Manifest = string(manifest)
 
-		config, err := crane.Config(ref, options...)
+		config, err := crane.Config(childRef, childOptions...)
 		if err != nil {
-			log.Printf("Error fetching config for image %s: %s (the config may be in the manifest itself)", ref, err)
+			log.Printf("Error fetching config for image %s: %s (the config may be in the manifest itself)", childRef, err)
 		}
 		result.Config = string(config)
 
-		out <- result
-	}(ref)
+		populateSignatures(result, childRef, reg, repo, opts, childOptions...)
 
-	return out
+		out <- result
+	}
 }
 
 //EnumRepository will read all images tagged in a specific repository on a remote registry and returns the results asynchronously.
 //If a list of tags is not supplied, a list will be enumerated from the registry's API.
-func EnumRepository(reg string, repo string, tags []string, options ...crane.Option) <-chan *ImageData {
+func EnumRepository(ctx context.Context, reg string, repo string, tags []string, opts *EnumOptions, options ...crane.Option) <-chan *ImageData {
+	opts = opts.orDefault()
 	out := make(chan *ImageData)
 	ref := fmt.Sprintf("%s/%s", reg, repo)
 	log.Printf("Repo: %s", ref)
@@ -163,7 +471,7 @@ func EnumRepository(reg string, repo string, tags []string, options ...crane.Opt
 
 		if len(tags) == 0 {
 			var err error
-			tags, err = crane.ListTags(ref, options...)
+			tags, err = listTags(ref, opts.AnonFirst, options...)
 
 			if err != nil {
 				log.Printf("Error listing tags for %s: %s", ref, err)
@@ -173,31 +481,43 @@ func EnumRepository(reg string, repo string, tags []string, options ...crane.Opt
 					Repository: repo,
 					Error:      err,
 				}
+				if opts.FailFast {
+					return
+				}
 			}
 		}
 
-		var wg sync.WaitGroup
+		g, gctx := errgroup.WithContext(ctx)
+		if opts.Concurrency.Tags > 0 {
+			g.SetLimit(opts.Concurrency.Tags)
+		}
 
 		for _, tag := range tags {
-			wg.Add(1)
-			go func(tag string) {
-				defer wg.Done()
-				images := EnumImage(reg, repo, tag, options...)
+			tag := tag
+			g.Go(func() error {
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+				images := EnumImage(gctx, reg, repo, tag, opts, options...)
 				for image := range images {
 					out <- image
+					if opts.FailFast && image.Error != nil {
+						return image.Error
+					}
 				}
-			}(tag)
+				return nil
+			})
 		}
 
-		wg.Wait()
-		return
+		g.Wait()
 	}(ref)
 	return out
 }
 
 //EnumRegistry will read all images cataloged on a remote registry and returns the results asynchronously.
 //If lists of repositories and tags are not supplied, lists will be enumerated from the registry's API.
-func EnumRegistry(reg string, repos []string, tags []string, options ...crane.Option) <-chan *ImageData {
+func EnumRegistry(ctx context.Context, reg string, repos []string, tags []string, opts *EnumOptions, options ...crane.Option) <-chan *ImageData {
+	opts = opts.orDefault()
 	out := make(chan *ImageData)
 	log.Printf("Registry: %s\n", reg)
 
@@ -206,7 +526,7 @@ func EnumRegistry(reg string, repos []string, tags []string, options ...crane.Op
 
 		if len(repos) == 0 {
 			var err error
-			repos, err = crane.Catalog(reg, options...)
+			repos, err = catalog(reg, opts.AnonFirst, options...)
 
 			if err != nil {
 				log.Printf("Error listing repos for %s: (%T) %s", reg, err, err)
@@ -215,30 +535,45 @@ func EnumRegistry(reg string, repos []string, tags []string, options ...crane.Op
 					Registry:  reg,
 					Error:     err,
 				}
+				if opts.FailFast {
+					return
+				}
 			}
 		}
 
-		var wg sync.WaitGroup
+		g, gctx := errgroup.WithContext(ctx)
+		if opts.Concurrency.Repositories > 0 {
+			g.SetLimit(opts.Concurrency.Repositories)
+		}
 
 		for _, repo := range repos {
-			wg.Add(1)
-			go func(repo string) {
-				defer wg.Done()
-				images := EnumRepository(reg, repo, tags, options...)
+			repo := repo
+			g.Go(func() error {
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+				images := EnumRepository(gctx, reg, repo, tags, opts, options...)
 				for image := range images {
 					out <- image
+					if opts.FailFast && image.Error != nil {
+						return image.Error
+					}
 				}
-			}(repo)
-
+				return nil
+			})
 		}
-		wg.Wait()
+
+		g.Wait()
 	}()
 	return out
 }
 
 //EnumRegistries will read all images cataloged by a set of remote registries and returns the results asynchronously.
-//If lists of repositories and tags are not supplied, lists will be enumerated from the registry's API.
-func EnumRegistries(regs []string, repos []string, tags []string, options ...crane.Option) <-chan *ImageData {
+//If lists of repositories and tags are not supplied, lists will be enumerated from the registry's API. ctx, when
+//cancelled (e.g. on SIGINT/SIGTERM), aborts in-flight catalog/tag listings and pulls and stops scheduling new ones.
+func EnumRegistries(ctx context.Context, regs []string, repos []string, tags []string, opts *EnumOptions, options ...crane.Option) <-chan *ImageData {
+	opts = opts.orDefault()
+	options = append(append([]crane.Option{}, options...), crane.WithContext(ctx))
 	out := make(chan *ImageData)
 	go func() {
 		defer close(out)
@@ -253,20 +588,29 @@ func EnumRegistries(regs []string, repos []string, tags []string, options ...cra
 			return
 		}
 
-		var wg sync.WaitGroup
+		g, gctx := errgroup.WithContext(ctx)
+		if opts.Concurrency.Registries > 0 {
+			g.SetLimit(opts.Concurrency.Registries)
+		}
 
 		for _, reg := range regs {
-			wg.Add(1)
-			go func(reg string) {
-				defer wg.Done()
-				images := EnumRegistry(reg, repos, tags, options...)
+			reg := reg
+			g.Go(func() error {
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+				images := EnumRegistry(gctx, reg, repos, tags, opts, options...)
 				for image := range images {
 					out <- image
+					if opts.FailFast && image.Error != nil {
+						return image.Error
+					}
 				}
-			}(reg)
-
+				return nil
+			})
 		}
-		wg.Wait()
+
+		g.Wait()
 	}()
 	return out
 }
@@ -0,0 +1,117 @@
+package pillage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+//Platform identifies the OS/architecture combination a single manifest in a
+//multi-arch image index targets.
+type Platform struct {
+	OS           string
+	Architecture string
+	Variant      string
+	OSVersion    string
+}
+
+//String renders a Platform the way it's given on the command line, e.g. "linux/arm64/v8".
+func (p *Platform) String() string {
+	if p == nil {
+		return ""
+	}
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+//dirName is the path segment Store lays a platform's output under, e.g. "linux-arm64-v8".
+func (p *Platform) dirName() string {
+	name := p.OS + "-" + p.Architecture
+	if p.Variant != "" {
+		name += "-" + p.Variant
+	}
+	return name
+}
+
+//ParsePlatform parses a --platform value of the form "os/arch" or
+//"os/arch/variant", as used by docker and crane.
+func ParsePlatform(value string) (Platform, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Platform{}, fmt.Errorf("invalid --platform value %q, want os/arch or os/arch/variant", value)
+	}
+	p := Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+//PlatformFilter selects which platforms of a multi-arch image index EnumImage
+//should recurse into.
+type PlatformFilter struct {
+	//All, if set, recurses into every platform in the index.
+	All bool
+	//Platforms, when All is false, restricts recursion to these os/arch[/variant]
+	//combinations. If also empty, EnumImage falls back to the platform pilreg
+	//itself is running on, matching `docker pull`'s default behaviour.
+	Platforms []Platform
+}
+
+//matches reports whether desc's platform should be enumerated under f.
+func (f *PlatformFilter) matches(p *v1.Platform) bool {
+	if f == nil || f.All {
+		return true
+	}
+	if len(f.Platforms) == 0 {
+		return p != nil && p.OS == runtime.GOOS && p.Architecture == runtime.GOARCH
+	}
+	if p == nil {
+		return false
+	}
+	for _, want := range f.Platforms {
+		if want.OS == p.OS && want.Architecture == p.Architecture && (want.Variant == "" || want.Variant == p.Variant) {
+			return true
+		}
+	}
+	return false
+}
+
+//platformOf converts a go-containerregistry v1.Platform into pillage's own
+//Platform, returning nil for an unset platform.
+func platformOf(p *v1.Platform) *Platform {
+	if p == nil {
+		return nil
+	}
+	return &Platform{OS: p.OS, Architecture: p.Architecture, Variant: p.Variant, OSVersion: p.OSVersion}
+}
+
+//isIndexMediaType reports whether mediaType identifies a manifest
+//list/image index rather than a single image manifest.
+func isIndexMediaType(mediaType string) bool {
+	return mediaType == string(types.DockerManifestList) || mediaType == string(types.OCIImageIndex)
+}
+
+//manifestMediaType pulls just the top-level "mediaType" field out of a raw
+//manifest or index so the caller can decide how to parse the rest of it.
+func manifestMediaType(manifest []byte) string {
+	var header struct {
+		MediaType string `json:"mediaType"`
+	}
+	_ = json.Unmarshal(manifest, &header)
+	return header.MediaType
+}
+
+//parseImageIndex parses the manifests list out of a raw image index/manifest
+//list.
+func parseImageIndex(manifest []byte) (*v1.IndexManifest, error) {
+	return v1.ParseIndexManifest(bytes.NewReader(manifest))
+}
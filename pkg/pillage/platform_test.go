@@ -0,0 +1,134 @@
+package pillage
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestParsePlatform(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    Platform
+		wantErr bool
+	}{
+		{
+			name:  "os/arch",
+			value: "linux/amd64",
+			want:  Platform{OS: "linux", Architecture: "amd64"},
+		},
+		{
+			name:  "os/arch/variant",
+			value: "linux/arm64/v8",
+			want:  Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		},
+		{
+			name:    "missing arch",
+			value:   "linux",
+			wantErr: true,
+		},
+		{
+			name:    "too many segments",
+			value:   "linux/arm64/v8/extra",
+			wantErr: true,
+		},
+		{
+			name:    "empty segment",
+			value:   "linux/",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParsePlatform(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePlatform(%q) = nil error, want one", tc.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePlatform(%q) returned error: %v", tc.value, err)
+			}
+			if got != tc.want {
+				t.Errorf("ParsePlatform(%q) = %+v, want %+v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPlatformFilterMatches(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter *PlatformFilter
+		p      *v1.Platform
+		want   bool
+	}{
+		{
+			name:   "nil filter matches everything",
+			filter: nil,
+			p:      &v1.Platform{OS: "linux", Architecture: "amd64"},
+			want:   true,
+		},
+		{
+			name:   "All matches everything including a nil platform",
+			filter: &PlatformFilter{All: true},
+			p:      nil,
+			want:   true,
+		},
+		{
+			name:   "empty filter matches only the host platform",
+			filter: &PlatformFilter{},
+			p:      &v1.Platform{OS: "bogus-os", Architecture: "bogus-arch"},
+			want:   false,
+		},
+		{
+			name:   "empty filter rejects a nil platform",
+			filter: &PlatformFilter{},
+			p:      nil,
+			want:   false,
+		},
+		{
+			name: "explicit platform list matches os/arch",
+			filter: &PlatformFilter{Platforms: []Platform{
+				{OS: "linux", Architecture: "arm64"},
+			}},
+			p:    &v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+			want: true,
+		},
+		{
+			name: "explicit platform list honours a variant when given",
+			filter: &PlatformFilter{Platforms: []Platform{
+				{OS: "linux", Architecture: "arm", Variant: "v7"},
+			}},
+			p:    &v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			want: false,
+		},
+		{
+			name: "explicit platform list rejects a non-matching arch",
+			filter: &PlatformFilter{Platforms: []Platform{
+				{OS: "linux", Architecture: "amd64"},
+			}},
+			p:    &v1.Platform{OS: "linux", Architecture: "arm64"},
+			want: false,
+		},
+		{
+			name: "explicit platform list rejects a nil platform",
+			filter: &PlatformFilter{Platforms: []Platform{
+				{OS: "linux", Architecture: "amd64"},
+			}},
+			p:    nil,
+			want: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.matches(tc.p); got != tc.want {
+				t.Errorf("matches(%+v) = %v, want %v", tc.p, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package pillage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+//Reference identifies a single image within a repository, wrapping
+//name.Reference so a tag, a bare digest, and a tag pinned to a digest are all
+//validated and rendered the same way go-containerregistry itself does.
+type Reference struct {
+	Repository string
+	//Selector is the part of a --tags entry naming the image within
+	//Repository: a tag ("v1.2.3"), a bare digest ("sha256:..."), or a tag
+	//pinned to a digest ("v1.2.3@sha256:...").
+	Selector string
+
+	ref name.Reference
+}
+
+//ParseReference parses selector against repository, accepting a bare tag, a
+//bare digest, or a tag@digest pin.
+func ParseReference(repository, selector string) (Reference, error) {
+	sep := ":"
+	if strings.HasPrefix(selector, "sha256:") {
+		sep = "@"
+	}
+
+	ref, err := name.ParseReference(repository + sep + selector)
+	if err != nil {
+		return Reference{}, fmt.Errorf("invalid reference %s%s%s: %w", repository, sep, selector, err)
+	}
+	return Reference{Repository: repository, Selector: selector, ref: ref}, nil
+}
+
+//String renders the full image reference, e.g. "example.com/repo@sha256:...".
+func (r Reference) String() string {
+	return r.ref.String()
+}
+
+//buildReference combines a registry, repository, and a --tags selector into
+//the full image reference string crane expects, validating it via
+//name.ParseReference so a malformed entry fails fast with a clear error
+//instead of a confusing registry-side one.
+func buildReference(reg, repo, selector string) (string, error) {
+	ref, err := ParseReference(reg+"/"+repo, selector)
+	if err != nil {
+		return "", err
+	}
+	return ref.String(), nil
+}
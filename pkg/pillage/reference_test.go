@@ -0,0 +1,116 @@
+package pillage
+
+import "testing"
+
+func TestParseReference(t *testing.T) {
+	cases := []struct {
+		name       string
+		repository string
+		selector   string
+		wantErr    bool
+		wantString string
+	}{
+		{
+			name:       "bare tag",
+			repository: "example.com/repo",
+			selector:   "v1.2.3",
+			wantString: "example.com/repo:v1.2.3",
+		},
+		{
+			name:       "bare digest",
+			repository: "example.com/repo",
+			selector:   "sha256:" + digestHex,
+			wantString: "example.com/repo@sha256:" + digestHex,
+		},
+		{
+			name:       "tag pinned to a digest",
+			repository: "example.com/repo",
+			selector:   "v1.2.3@sha256:" + digestHex,
+			wantString: "example.com/repo:v1.2.3@sha256:" + digestHex,
+		},
+		{
+			name:       "invalid selector",
+			repository: "example.com/repo",
+			selector:   "not a valid tag!!",
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ref, err := ParseReference(tc.repository, tc.selector)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseReference(%q, %q) = nil error, want one", tc.repository, tc.selector)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseReference(%q, %q) returned error: %v", tc.repository, tc.selector, err)
+			}
+			if ref.Repository != tc.repository {
+				t.Errorf("Repository = %q, want %q", ref.Repository, tc.repository)
+			}
+			if ref.Selector != tc.selector {
+				t.Errorf("Selector = %q, want %q", ref.Selector, tc.selector)
+			}
+			if got := ref.String(); got != tc.wantString {
+				t.Errorf("String() = %q, want %q", got, tc.wantString)
+			}
+		})
+	}
+}
+
+func TestBuildReference(t *testing.T) {
+	cases := []struct {
+		name    string
+		reg     string
+		repo    string
+		tag     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "registry repo and tag combine into a full reference",
+			reg:  "example.com",
+			repo: "group/app",
+			tag:  "latest",
+			want: "example.com/group/app:latest",
+		},
+		{
+			name: "digest selector uses the @ separator",
+			reg:  "example.com",
+			repo: "group/app",
+			tag:  "sha256:" + digestHex,
+			want: "example.com/group/app@sha256:" + digestHex,
+		},
+		{
+			name:    "invalid tag fails fast",
+			reg:     "example.com",
+			repo:    "group/app",
+			tag:     "in valid",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := buildReference(tc.reg, tc.repo, tc.tag)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("buildReference(%q, %q, %q) = nil error, want one", tc.reg, tc.repo, tc.tag)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildReference(%q, %q, %q) returned error: %v", tc.reg, tc.repo, tc.tag, err)
+			}
+			if got != tc.want {
+				t.Errorf("buildReference(%q, %q, %q) = %q, want %q", tc.reg, tc.repo, tc.tag, got, tc.want)
+			}
+		})
+	}
+}
+
+//digestHex is a syntactically valid sha256 hex digest used across test cases.
+const digestHex = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
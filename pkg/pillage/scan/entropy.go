@@ -0,0 +1,54 @@
+package scan
+
+import (
+	"math"
+	"regexp"
+)
+
+//EntropyOptions configures the high-entropy-string fallback, which catches
+//secrets that don't match any known format.
+type EntropyOptions struct {
+	MinLength int
+	Threshold float64
+}
+
+//DefaultEntropyOptions mirrors common secret-scanner defaults: look at
+//base64/hex-ish tokens of at least 20 characters with Shannon entropy at or
+//above 4.5 bits/char.
+var DefaultEntropyOptions = EntropyOptions{MinLength: 20, Threshold: 4.5}
+
+var tokenPattern = regexp.MustCompile(`[A-Za-z0-9+/_=-]{20,}`)
+
+//findHighEntropyStrings returns every token in data that is at least
+//opts.MinLength characters long and whose Shannon entropy meets opts.Threshold.
+func findHighEntropyStrings(data []byte, opts EntropyOptions) []string {
+	var out []string
+	for _, tok := range tokenPattern.FindAll(data, -1) {
+		if len(tok) < opts.MinLength {
+			continue
+		}
+		if shannonEntropy(tok) >= opts.Threshold {
+			out = append(out, string(tok))
+		}
+	}
+	return out
+}
+
+//shannonEntropy computes the Shannon entropy of b, in bits per byte.
+func shannonEntropy(b []byte) float64 {
+	var counts [256]int
+	for _, c := range b {
+		counts[c]++
+	}
+
+	n := float64(len(b))
+	var entropy float64
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
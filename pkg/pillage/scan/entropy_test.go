@@ -0,0 +1,72 @@
+package scan
+
+import (
+	"math"
+	"testing"
+)
+
+func TestShannonEntropy(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want float64
+	}{
+		{name: "empty", in: "", want: 0},
+		{name: "single repeated byte", in: "aaaaaaaa", want: 0},
+		{name: "two equally likely bytes", in: "abababab", want: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := shannonEntropy([]byte(tc.in))
+			if math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("shannonEntropy(%q) = %v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFindHighEntropyStrings(t *testing.T) {
+	opts := EntropyOptions{MinLength: 20, Threshold: 4.5}
+
+	cases := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "no tokens at all",
+			data: "just some plain English text",
+			want: nil,
+		},
+		{
+			name: "token too short to qualify",
+			data: "token=abc123",
+			want: nil,
+		},
+		{
+			name: "low entropy token is skipped",
+			data: "key: aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			want: nil,
+		},
+		{
+			name: "high entropy token is reported",
+			data: "token: kX92mQeR7pL4vT1zN6wY8bH3cJ0sF5dA",
+			want: []string{"kX92mQeR7pL4vT1zN6wY8bH3cJ0sF5dA"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := findHighEntropyStrings([]byte(tc.data), opts)
+			if len(got) != len(tc.want) {
+				t.Fatalf("findHighEntropyStrings(%q) = %v, want %v", tc.data, got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("findHighEntropyStrings(%q)[%d] = %q, want %q", tc.data, i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package scan
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"log"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+//ScanImage scans every regular file in every layer of img, without ever
+//untarring a layer to disk: each layer's uncompressed tar stream is read
+//directly, and each file's content is capped at sizeCap bytes (0 uses
+//DefaultSizeCap) before being handed to ScanBytes.
+func (s *Scanner) ScanImage(img v1.Image, sizeCap int64) ([]Finding, error) {
+	if sizeCap <= 0 {
+		sizeCap = DefaultSizeCap
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("listing layers: %w", err)
+	}
+
+	var findings []Finding
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			log.Printf("Error getting layer digest, skipping layer: %v", err)
+			continue
+		}
+
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			log.Printf("Error reading layer %s, skipping layer: %v", digest, err)
+			continue
+		}
+
+		findings = append(findings, s.scanLayer(digest.String(), rc, sizeCap)...)
+		rc.Close()
+	}
+	return findings, nil
+}
+
+//scanLayer walks the tar stream of a single layer, scanning each regular
+//file's path and (size-capped) content.
+func (s *Scanner) scanLayer(digest string, r io.Reader, sizeCap int64) []Finding {
+	var findings []Finding
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Error reading layer %s: %v", digest, err)
+			break
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		size := hdr.Size
+		if size > sizeCap {
+			size = sizeCap
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(tr, data); err != nil && err != io.ErrUnexpectedEOF {
+			log.Printf("Error reading %s in layer %s: %v", hdr.Name, digest, err)
+			continue
+		}
+
+		findings = append(findings, s.ScanBytes(digest, hdr.Name, data)...)
+	}
+
+	return findings
+}
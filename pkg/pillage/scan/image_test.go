@@ -0,0 +1,84 @@
+package scan
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+//fakeLayer is a minimal v1.Layer whose Digest/Uncompressed calls can be made
+//to fail, so ScanImage's per-layer error handling can be exercised without a
+//real image.
+type fakeLayer struct {
+	digest    v1.Hash
+	digestErr error
+	content   []byte
+	readErr   error
+}
+
+func (f *fakeLayer) Digest() (v1.Hash, error)            { return f.digest, f.digestErr }
+func (f *fakeLayer) DiffID() (v1.Hash, error)            { return f.digest, f.digestErr }
+func (f *fakeLayer) Size() (int64, error)                { return int64(len(f.content)), nil }
+func (f *fakeLayer) MediaType() (types.MediaType, error) { return types.DockerLayer, nil }
+func (f *fakeLayer) Compressed() (io.ReadCloser, error)  { return f.Uncompressed() }
+func (f *fakeLayer) Uncompressed() (io.ReadCloser, error) {
+	if f.readErr != nil {
+		return nil, f.readErr
+	}
+	return io.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+func tarWithFile(name string, content []byte) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	_ = tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	_, _ = tw.Write(content)
+	_ = tw.Close()
+	return buf.Bytes()
+}
+
+//fakeImage is a minimal v1.Image exposing only the layers ScanImage reads.
+type fakeImage struct {
+	v1.Image
+	layers []v1.Layer
+}
+
+func (f *fakeImage) Layers() ([]v1.Layer, error) { return f.layers, nil }
+
+func TestScanImageKeepsFindingsFromOtherLayersOnPerLayerError(t *testing.T) {
+	s, err := NewScanner("")
+	if err != nil {
+		t.Fatalf("NewScanner returned error: %v", err)
+	}
+
+	goodDigest, err := v1.NewHash("sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	if err != nil {
+		t.Fatalf("NewHash returned error: %v", err)
+	}
+
+	img := &fakeImage{layers: []v1.Layer{
+		&fakeLayer{digestErr: errors.New("corrupt digest")},
+		&fakeLayer{digest: goodDigest, readErr: errors.New("truncated layer")},
+		&fakeLayer{digest: goodDigest, content: tarWithFile(".env", []byte("SECRET=1"))},
+	}}
+
+	findings, err := s.ScanImage(img, 0)
+	if err != nil {
+		t.Fatalf("ScanImage returned error: %v", err)
+	}
+
+	var sawDotenv bool
+	for _, f := range findings {
+		if f.RuleID == "dotenv-file" {
+			sawDotenv = true
+		}
+	}
+	if !sawDotenv {
+		t.Errorf("ScanImage() = %+v, want a dotenv-file finding from the last, readable layer", findings)
+	}
+}
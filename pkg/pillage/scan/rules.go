@@ -0,0 +1,96 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//Rule describes one pattern the scanner looks for. A rule may match on file
+//path (PathPattern), on content (Pattern, a regexp), or both; a rule with
+//neither never fires.
+type Rule struct {
+	ID          string `yaml:"id"`
+	Description string `yaml:"description"`
+	//PathPattern, if it ends in "/", flags any path containing it (e.g.
+	//".git/" to catch a checked-out git directory); otherwise it is matched
+	//against the file's base name with filepath.Match (e.g. "*.pem").
+	PathPattern string `yaml:"path_pattern,omitempty"`
+	Pattern     string `yaml:"pattern,omitempty"`
+
+	compiled *regexp.Regexp
+}
+
+//compile resolves Pattern into a regexp, if set.
+func (r *Rule) compile() error {
+	if r.Pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("rule %s: %w", r.ID, err)
+	}
+	r.compiled = re
+	return nil
+}
+
+//matchesPath reports whether name should be flagged by PathPattern alone.
+func (r *Rule) matchesPath(name string) bool {
+	if r.PathPattern == "" {
+		return false
+	}
+	if strings.HasSuffix(r.PathPattern, "/") {
+		return strings.Contains(name, r.PathPattern)
+	}
+	ok, _ := filepath.Match(r.PathPattern, filepath.Base(name))
+	return ok
+}
+
+//LoadRules reads additional rules from a YAML file of the form:
+//
+//	rules:
+//	  - id: my-custom-token
+//	    pattern: 'myco_[a-z0-9]{32}'
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rules file %s: %w", path, err)
+	}
+
+	var doc struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing rules file %s: %w", path, err)
+	}
+
+	for i := range doc.Rules {
+		if err := doc.Rules[i].compile(); err != nil {
+			return nil, err
+		}
+	}
+	return doc.Rules, nil
+}
+
+//DefaultRules is the built-in rule set covering the credential formats most
+//commonly leaked into container images.
+var DefaultRules = []Rule{
+	{ID: "aws-access-key-id", Pattern: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{ID: "aws-secret-access-key", Pattern: `(?i)aws_secret_access_key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}["']?`},
+	{ID: "gcp-service-account", Pattern: `"type"\s*:\s*"service_account"`},
+	{ID: "azure-connection-string", Pattern: `(?i)DefaultEndpointsProtocol=https?;AccountName=[^;]+;AccountKey=[A-Za-z0-9+/=]{20,}`},
+	{ID: "slack-token", Pattern: `xox[baprs]-[0-9A-Za-z-]{10,}`},
+	{ID: "github-token", Pattern: `gh[pousr]_[A-Za-z0-9]{36}`},
+	{ID: "jwt", Pattern: `\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`},
+	{ID: "private-key", Pattern: `-----BEGIN (RSA |EC |OPENSSH |DSA |)PRIVATE KEY-----`},
+	{ID: "npmrc-token", Pattern: `(?i)//registry\.npmjs\.org/:_authToken=\S+`},
+	{ID: "pypirc-token", Pattern: `(?i)password\s*=\s*pypi-[A-Za-z0-9_-]{20,}`},
+	{ID: "dotenv-file", PathPattern: ".env"},
+	{ID: "git-directory", PathPattern: ".git/"},
+	{ID: "kubeconfig", PathPattern: "kubeconfig"},
+	{ID: "kubeconfig", PathPattern: ".kube/"},
+}
@@ -0,0 +1,57 @@
+package scan
+
+import "testing"
+
+func TestRuleMatchesPath(t *testing.T) {
+	cases := []struct {
+		name string
+		rule Rule
+		path string
+		want bool
+	}{
+		{
+			name: "no path pattern never matches",
+			rule: Rule{ID: "no-pattern"},
+			path: "anything",
+			want: false,
+		},
+		{
+			name: "directory pattern matches anywhere in the path",
+			rule: Rule{ID: "git-directory", PathPattern: ".git/"},
+			path: "app/.git/config",
+			want: true,
+		},
+		{
+			name: "directory pattern does not match a bare file",
+			rule: Rule{ID: "git-directory", PathPattern: ".git/"},
+			path: "app/.gitignore",
+			want: false,
+		},
+		{
+			name: "glob pattern matches the base name",
+			rule: Rule{ID: "pem-file", PathPattern: "*.pem"},
+			path: "etc/certs/server.pem",
+			want: true,
+		},
+		{
+			name: "glob pattern does not match the directory portion",
+			rule: Rule{ID: "pem-file", PathPattern: "*.pem"},
+			path: "etc/pem/server.crt",
+			want: false,
+		},
+		{
+			name: "exact base name pattern",
+			rule: Rule{ID: "dotenv-file", PathPattern: ".env"},
+			path: "app/.env",
+			want: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.rule.matchesPath(tc.path); got != tc.want {
+				t.Errorf("matchesPath(%q) = %v, want %v", tc.path, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+//Package scan implements secret-scanning over the configs, manifests, and layer
+//filesystems that pilreg enumerates, so that leaked credentials surface
+//directly in scan results instead of requiring a manual review of every pulled
+//image.
+package scan
+
+import "fmt"
+
+//Finding is a single secret-scanning hit, carrying enough context to triage
+//without re-downloading the image: which layer it came from, which file, which
+//rule fired, and a redacted snippet of the match.
+type Finding struct {
+	LayerDigest string `json:"layer_digest,omitempty"`
+	Path        string `json:"path"`
+	RuleID      string `json:"rule_id"`
+	Snippet     string `json:"snippet"`
+}
+
+//Scanner runs the built-in and user-supplied rules, plus the entropy fallback,
+//over named blobs of data (image configs, manifests, and files extracted from
+//layer tarballs).
+type Scanner struct {
+	rules   []Rule
+	entropy EntropyOptions
+}
+
+//DefaultSizeCap bounds how much of a single file's content a Scanner will
+//buffer and inspect, so a multi-gigabyte layer file can't exhaust memory.
+const DefaultSizeCap = 10 * 1024 * 1024
+
+//NewScanner builds a Scanner from the built-in rule set plus any additional
+//rules loaded from rulesPath (blank to skip loading a rules file).
+func NewScanner(rulesPath string) (*Scanner, error) {
+	rules := make([]Rule, len(DefaultRules))
+	copy(rules, DefaultRules)
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, err
+		}
+	}
+
+	if rulesPath != "" {
+		extra, err := LoadRules(rulesPath)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, extra...)
+	}
+
+	return &Scanner{rules: rules, entropy: DefaultEntropyOptions}, nil
+}
+
+//ScanBytes runs every rule, plus the entropy fallback, over a single named
+//blob: an image config, a manifest, or a file extracted from a layer tarball.
+//layerDigest is blank for blobs that don't come from a layer.
+func (s *Scanner) ScanBytes(layerDigest, path string, data []byte) []Finding {
+	var findings []Finding
+
+	for _, rule := range s.rules {
+		if rule.matchesPath(path) {
+			findings = append(findings, Finding{
+				LayerDigest: layerDigest,
+				Path:        path,
+				RuleID:      rule.ID,
+				Snippet:     redact(path),
+			})
+		}
+
+		if rule.compiled == nil {
+			continue
+		}
+		for _, match := range rule.compiled.FindAll(data, -1) {
+			findings = append(findings, Finding{
+				LayerDigest: layerDigest,
+				Path:        path,
+				RuleID:      rule.ID,
+				Snippet:     redact(string(match)),
+			})
+		}
+	}
+
+	for _, token := range findHighEntropyStrings(data, s.entropy) {
+		findings = append(findings, Finding{
+			LayerDigest: layerDigest,
+			Path:        path,
+			RuleID:      "high-entropy-string",
+			Snippet:     redact(token),
+		})
+	}
+
+	return findings
+}
+
+//redact keeps a few characters at each end of a match so a finding stays
+//identifiable without persisting the secret itself.
+func redact(s string) string {
+	if len(s) <= 8 {
+		return "****"
+	}
+	return fmt.Sprintf("%s...%s", s[:4], s[len(s)-4:])
+}
@@ -0,0 +1,266 @@
+package pillage
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+//Signature is one cosign signature attached to an image via the
+//sha256-<digest>.sig tag convention.
+type Signature struct {
+	Tag         string
+	MediaType   string
+	Payload     string
+	Annotations map[string]string `json:",omitempty"`
+	Verified    bool
+	VerifyError string `json:",omitempty"`
+}
+
+//Attestation is one cosign in-toto attestation attached to an image via the
+//sha256-<digest>.att tag convention.
+type Attestation struct {
+	Tag           string
+	PredicateType string
+	Payload       string
+}
+
+//SBOM is a software bill of materials attached to an image via the
+//sha256-<digest>.sbom tag convention.
+type SBOM struct {
+	Tag       string
+	MediaType string
+	Payload   string
+}
+
+//CosignOptions configures optional verification of discovered cosign
+//signatures. Keyless (Fulcio/Rekor) verification is not implemented: with
+//PublicKey unset, signatures are still recorded but left unverified.
+type CosignOptions struct {
+	//PublicKey, if set, is used to verify each signature's ECDSA signature
+	//annotation directly against the signed payload.
+	PublicKey *ecdsa.PublicKey
+}
+
+//LoadCosignPublicKey reads a PEM-encoded ECDSA public key, as produced by
+//`cosign generate-key-pair`, from path.
+func LoadCosignPublicKey(path string) (*ecdsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cosign public key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", path)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cosign public key %s: %w", path, err)
+	}
+
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ECDSA public key", path)
+	}
+	return key, nil
+}
+
+//cosignTag derives the cosign tag convention for suffix ("sig", "att", or
+//"sbom") from an image manifest digest, e.g. "sha256-abcd1234....sig".
+func cosignTag(digest v1.Hash, suffix string) string {
+	return fmt.Sprintf("%s-%s.%s", digest.Algorithm, digest.Hex, suffix)
+}
+
+//cosignSignatureAnnotation is the annotation cosign attaches to each
+//simple-signing layer, holding the base64-encoded signature over that
+//layer's content.
+const cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+//EnumSignatures probes the cosign tag convention for digest's signatures,
+//attestations, and SBOMs, fetching whichever of the sha256-<digest>.sig/.att/.sbom
+//tags exist in repo. A missing tag is not an error: most images aren't signed.
+func EnumSignatures(reg, repo string, digest v1.Hash, cosignOpts *CosignOptions, options ...crane.Option) ([]Signature, []Attestation, []SBOM) {
+	sigRef := fmt.Sprintf("%s/%s:%s", reg, repo, cosignTag(digest, "sig"))
+	attRef := fmt.Sprintf("%s/%s:%s", reg, repo, cosignTag(digest, "att"))
+	sbomRef := fmt.Sprintf("%s/%s:%s", reg, repo, cosignTag(digest, "sbom"))
+
+	signatures := fetchSignatures(sigRef, cosignTag(digest, "sig"), cosignOpts, options...)
+	attestations := fetchAttestations(attRef, cosignTag(digest, "att"), options...)
+	sboms := fetchSBOMs(sbomRef, cosignTag(digest, "sbom"), options...)
+
+	return signatures, attestations, sboms
+}
+
+func readLayer(layer v1.Layer) ([]byte, error) {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func fetchSignatures(ref, tag string, cosignOpts *CosignOptions, options ...crane.Option) []Signature {
+	img, err := crane.Pull(ref, options...)
+	if err != nil {
+		return nil
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		log.Printf("Error reading signature manifest %s: %v", ref, err)
+		return nil
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		log.Printf("Error reading signature layers %s: %v", ref, err)
+		return nil
+	}
+
+	var signatures []Signature
+	for i, layer := range layers {
+		data, err := readLayer(layer)
+		if err != nil {
+			log.Printf("Error reading signature layer %s: %v", ref, err)
+			continue
+		}
+
+		sig := Signature{Tag: tag, Payload: string(data)}
+		if i < len(manifest.Layers) {
+			sig.MediaType = string(manifest.Layers[i].MediaType)
+			sig.Annotations = manifest.Layers[i].Annotations
+		}
+
+		if cosignOpts != nil && cosignOpts.PublicKey != nil {
+			verifySignature(&sig, data, cosignOpts.PublicKey)
+		}
+
+		signatures = append(signatures, sig)
+	}
+	return signatures
+}
+
+//verifySignature checks the ECDSA signature cosign stores in the
+//dev.cosignproject.cosign/signature annotation against the signed payload.
+func verifySignature(sig *Signature, payload []byte, key *ecdsa.PublicKey) {
+	encoded, ok := sig.Annotations[cosignSignatureAnnotation]
+	if !ok {
+		sig.VerifyError = "no signature annotation present"
+		return
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		sig.VerifyError = fmt.Sprintf("decoding signature: %v", err)
+		return
+	}
+
+	sum := sha256.Sum256(payload)
+	if ecdsa.VerifyASN1(key, sum[:], raw) {
+		sig.Verified = true
+	} else {
+		sig.VerifyError = "signature does not match the supplied public key"
+	}
+}
+
+//dsseEnvelope is the subset of a Dead Simple Signing Envelope
+//(https://github.com/secure-systems-lab/dsse) cosign wraps in-toto
+//attestations in.
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+}
+
+func fetchAttestations(ref, tag string, options ...crane.Option) []Attestation {
+	img, err := crane.Pull(ref, options...)
+	if err != nil {
+		return nil
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		log.Printf("Error reading attestation layers %s: %v", ref, err)
+		return nil
+	}
+
+	var attestations []Attestation
+	for _, layer := range layers {
+		data, err := readLayer(layer)
+		if err != nil {
+			log.Printf("Error reading attestation layer %s: %v", ref, err)
+			continue
+		}
+
+		var envelope dsseEnvelope
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			log.Printf("Error parsing DSSE envelope %s: %v", ref, err)
+			continue
+		}
+
+		payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+		if err != nil {
+			log.Printf("Error decoding attestation payload %s: %v", ref, err)
+			continue
+		}
+
+		var statement struct {
+			PredicateType string `json:"predicateType"`
+		}
+		_ = json.Unmarshal(payload, &statement)
+
+		attestations = append(attestations, Attestation{
+			Tag:           tag,
+			PredicateType: statement.PredicateType,
+			Payload:       string(payload),
+		})
+	}
+	return attestations
+}
+
+func fetchSBOMs(ref, tag string, options ...crane.Option) []SBOM {
+	img, err := crane.Pull(ref, options...)
+	if err != nil {
+		return nil
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		log.Printf("Error reading SBOM manifest %s: %v", ref, err)
+		return nil
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		log.Printf("Error reading SBOM layers %s: %v", ref, err)
+		return nil
+	}
+
+	var sboms []SBOM
+	for i, layer := range layers {
+		data, err := readLayer(layer)
+		if err != nil {
+			log.Printf("Error reading SBOM layer %s: %v", ref, err)
+			continue
+		}
+
+		sbom := SBOM{Tag: tag, Payload: string(data)}
+		if i < len(manifest.Layers) {
+			sbom.MediaType = string(manifest.Layers[i].MediaType)
+		}
+		sboms = append(sboms, sbom)
+	}
+	return sboms
+}
@@ -0,0 +1,101 @@
+package pillage
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestCosignTag(t *testing.T) {
+	hash, err := v1.NewHash("sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	if err != nil {
+		t.Fatalf("NewHash returned error: %v", err)
+	}
+
+	cases := []struct {
+		suffix string
+		want   string
+	}{
+		{suffix: "sig", want: "sha256-e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855.sig"},
+		{suffix: "att", want: "sha256-e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855.att"},
+		{suffix: "sbom", want: "sha256-e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855.sbom"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.suffix, func(t *testing.T) {
+			if got := cosignTag(hash, tc.suffix); got != tc.want {
+				t.Errorf("cosignTag(%v, %q) = %q, want %q", hash, tc.suffix, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	payload := []byte("signed payload")
+	sum := sha256.Sum256(payload)
+	raw, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatalf("SignASN1 returned error: %v", err)
+	}
+	validSig := base64.StdEncoding.EncodeToString(raw)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		wantVerify  bool
+		wantErr     bool
+	}{
+		{
+			name:        "no annotation present",
+			annotations: nil,
+			wantErr:     true,
+		},
+		{
+			name:        "invalid base64",
+			annotations: map[string]string{cosignSignatureAnnotation: "not base64!!"},
+			wantErr:     true,
+		},
+		{
+			name:        "signature does not match",
+			annotations: map[string]string{cosignSignatureAnnotation: validSig},
+			wantVerify:  false,
+			wantErr:     true,
+		},
+		{
+			name:        "valid signature verifies",
+			annotations: map[string]string{cosignSignatureAnnotation: validSig},
+			wantVerify:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sig := &Signature{Annotations: tc.annotations}
+			useKey := &key.PublicKey
+			if tc.name == "signature does not match" {
+				useKey = &otherKey.PublicKey
+			}
+			verifySignature(sig, payload, useKey)
+			if sig.Verified != tc.wantVerify {
+				t.Errorf("Verified = %v, want %v", sig.Verified, tc.wantVerify)
+			}
+			if (sig.VerifyError != "") != tc.wantErr {
+				t.Errorf("VerifyError = %q, wantErr %v", sig.VerifyError, tc.wantErr)
+			}
+		})
+	}
+}